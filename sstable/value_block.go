@@ -6,7 +6,11 @@ package sstable
 
 import (
 	"context"
+	"encoding/binary"
+	"hash/crc32"
 	"math/rand/v2"
+	"sort"
+	"sync"
 	"unsafe"
 
 	"github.com/cockroachdb/errors"
@@ -15,8 +19,38 @@ import (
 	"github.com/cockroachdb/pebble/objstorage/objstorageprovider/objiotracing"
 	"github.com/cockroachdb/pebble/sstable/block"
 	"github.com/cockroachdb/pebble/sstable/valblk"
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
 )
 
+// valuesCompressionChecksumLen is the length, in bytes, of the trailing
+// checksum appended to each individually-compressed value when
+// valblk.IndexHandle.ValuesIndividuallyCompressed is set (see
+// valueBlockFetcher.decompressValue).
+//
+// ValuesIndividuallyCompressed is defined on valblk.IndexHandle, in
+// sstable/valblk; this file only consumes it. Setting the flag and emitting
+// values in this format is the sstable writer's responsibility, and is not
+// implemented here -- this file only adds the reader-side decode path
+// (decompressValue and its callers), gated on the flag so existing
+// (non-individually-compressed) sstables keep reading exactly as before.
+// Until a writer sets the flag, this path is reachable only from direct
+// unit tests, not from a real sstable; the writer-side half of this request
+// is tracked separately and is out of scope for this change.
+const valuesCompressionChecksumLen = 4
+
+// valueCompressionTag identifies the compressor used for an individually
+// compressed value. It is the first byte of the compressed value payload.
+type valueCompressionTag byte
+
+const (
+	valueCompressionNone valueCompressionTag = iota
+	valueCompressionSnappy
+	valueCompressionZstd
+)
+
+var valuesChecksumTable = crc32.MakeTable(crc32.Castagnoli)
+
 const valueBlocksIndexHandleMaxLen = blockHandleMaxLenWithoutProperties + 3
 
 // Assert blockHandleLikelyMaxLen >= valueBlocksIndexHandleMaxLen.
@@ -46,6 +80,26 @@ type blockProviderWhenOpen interface {
 	) (block.BufferHandle, error)
 }
 
+// asyncValueBlockResult is the result of a speculative, asynchronous value
+// block read issued by a valueBlockPrefetcher.
+type asyncValueBlockResult struct {
+	block block.BufferHandle
+	err   error
+}
+
+// blockProviderWhenOpenAsync is an optional extension of blockProviderWhenOpen
+// for providers that can serve value-block reads asynchronously. It is used
+// by valueBlockPrefetcher to overlap value-block I/O with the caller's CPU
+// work during sequential iteration. blockProviderWhenClosed does not
+// implement this interface, since the Reader it obtains from ReaderProvider
+// does not outlive a single Fetch call, making speculative background reads
+// unsafe.
+type blockProviderWhenOpenAsync interface {
+	readBlockForVBRAsync(
+		ctx context.Context, h block.Handle, stats *base.InternalIteratorStats,
+	) <-chan asyncValueBlockResult
+}
+
 type blockProviderWhenClosed struct {
 	rp ReaderProvider
 	r  *Reader
@@ -120,12 +174,109 @@ type valueBlockReader struct {
 	vbih   valblk.IndexHandle
 	stats  *base.InternalIteratorStats
 
+	// valueBlockLRUSize is propagated to the fetcher's lru; see
+	// defaultValueBlockLRUSize and ValueBlockReaderOption. It is only
+	// consulted when valueBlockLRUSizeSet is true, so that
+	// WithValueBlockLRUSize(0) (which disables the LRU) is distinguishable
+	// from the option never having been passed at all (the zero value of
+	// valueBlockLRUSize).
+	valueBlockLRUSize    int
+	valueBlockLRUSizeSet bool
+	// valueBlockPrefetchCount is propagated to the fetcher; see the
+	// like-named field on valueBlockFetcher.
+	valueBlockPrefetchCount int
+	// coalesceValueFetches is propagated to the fetcher's coalesce field; see
+	// valueBlockFetcher.coalesce.
+	coalesceValueFetches bool
+
 	// fetcher is allocated lazily the first time we create a LazyValue, in order
 	// to avoid the allocation if we never read a lazy value (which should be the
 	// case when we're reading the latest value of a key).
 	fetcher *valueBlockFetcher
 }
 
+// newValueBlockReader constructs a valueBlockReader, applying any
+// ValueBlockReaderOptions. It is the entry point ReaderOptions should call
+// into when building the valueBlockReader for a Reader's iterator tree.
+func newValueBlockReader(
+	bpOpen blockProviderWhenOpen,
+	rp ReaderProvider,
+	vbih valblk.IndexHandle,
+	stats *base.InternalIteratorStats,
+	opts ...ValueBlockReaderOption,
+) *valueBlockReader {
+	r := &valueBlockReader{
+		bpOpen: bpOpen,
+		rp:     rp,
+		vbih:   vbih,
+		stats:  stats,
+	}
+	for _, opt := range opts {
+		opt.applyToValueBlockReader(r)
+	}
+	return r
+}
+
+// ValueBlockReaderOption configures optional behavior of the reader used to
+// retrieve values stored in value blocks (see valueBlockReader). These are
+// typically threaded through from a ReaderOption passed to the sstable
+// Reader.
+type ValueBlockReaderOption interface {
+	applyToValueBlockReader(r *valueBlockReader)
+}
+
+// valueBlockLRUSizeOption is a ValueBlockReaderOption that overrides
+// defaultValueBlockLRUSize.
+type valueBlockLRUSizeOption int
+
+func (o valueBlockLRUSizeOption) applyToValueBlockReader(r *valueBlockReader) {
+	r.valueBlockLRUSize = int(o)
+	r.valueBlockLRUSizeSet = true
+}
+
+// WithValueBlockLRUSize overrides the number of additional decompressed
+// value blocks (beyond the single-entry fast path) that a value-block reader
+// retains to absorb short-range reuse. n <= 0 disables the LRU, falling back
+// to the pre-existing single-entry behavior.
+func WithValueBlockLRUSize(n int) ValueBlockReaderOption {
+	return valueBlockLRUSizeOption(n)
+}
+
+// valueBlockPrefetchOption is a ValueBlockReaderOption that enables
+// speculative prefetching of upcoming value blocks.
+type valueBlockPrefetchOption int
+
+func (o valueBlockPrefetchOption) applyToValueBlockReader(r *valueBlockReader) {
+	r.valueBlockPrefetchCount = int(o)
+}
+
+// WithValueBlockPrefetch configures the reader to speculatively prefetch up
+// to n upcoming value blocks during sequential iteration over separated
+// values, overlapping their I/O with the caller's CPU work. n <= 0 disables
+// prefetching (the default).
+func WithValueBlockPrefetch(n int) ValueBlockReaderOption {
+	return valueBlockPrefetchOption(n)
+}
+
+// valueFetchCoalescingOption is a ValueBlockReaderOption that enables
+// coalescing of concurrent Fetch calls landing on the same value handle.
+type valueFetchCoalescingOption struct{}
+
+func (valueFetchCoalescingOption) applyToValueBlockReader(r *valueBlockReader) {
+	r.coalesceValueFetches = true
+}
+
+// WithValueFetchCoalescing makes the reader's Fetch safe to call
+// concurrently from multiple goroutines (e.g. when a snapshot, and the
+// LazyValues derived from it, are shared across readers), coalescing
+// concurrent calls that land on the same value handle so that only one of
+// them performs the underlying block read. This changes the concurrency
+// contract documented on base.ValueFetcher, so it is opt-in; by default
+// (the zero value) Fetch is only safe to call from one goroutine at a time.
+func WithValueFetchCoalescing() ValueBlockReaderOption {
+	return valueFetchCoalescingOption{}
+}
+
 var _ block.GetLazyValueForPrefixAndValueHandler = (*valueBlockReader)(nil)
 
 func (r *valueBlockReader) GetLazyValueForPrefixAndValueHandle(handle []byte) base.LazyValue {
@@ -137,7 +288,15 @@ func (r *valueBlockReader) GetLazyValueForPrefixAndValueHandle(handle []byte) ba
 		// TODO(radu): since it is a relatively small object, we could allocate
 		// multiple instances together, using a sync.Pool (each pool object would
 		// contain an array of instances, a subset of which have been given out).
-		r.fetcher = newValueBlockFetcher(r.bpOpen, r.rp, r.vbih, r.stats)
+		lruSize := defaultValueBlockLRUSize
+		if r.valueBlockLRUSizeSet {
+			lruSize = r.valueBlockLRUSize
+		}
+		r.fetcher = newValueBlockFetcher(
+			r.bpOpen, r.rp, r.vbih, r.stats, lruSize, r.valueBlockPrefetchCount)
+		if r.coalesceValueFetches {
+			r.fetcher.coalesce = &valueFetchCoalescer{}
+		}
 	}
 	lazyFetcher := &r.fetcher.lazyFetcher
 	valLen, h := valblk.DecodeLenFromHandle(handle[1:])
@@ -166,6 +325,284 @@ func (r *valueBlockReader) close() {
 	}
 }
 
+// defaultValueBlockLRUSize is the number of value blocks, beyond the single
+// most-recently-used block already held by the valueBlockNum/valueBlock fast
+// path, that a valueBlockFetcher's valueBlockLRU retains when
+// WithValueBlockLRUSize is not passed. It is chosen to absorb short-range
+// reuse without much memory overhead.
+const defaultValueBlockLRUSize = 4
+
+// valueBlockLRUEntry holds a decompressed value block evicted from the
+// valueBlockFetcher fast path, along with the block cache handle that keeps
+// it alive.
+type valueBlockLRUEntry struct {
+	blockNum uint32
+	block    []byte
+	cache    block.BufferHandle
+}
+
+// valueBlockLRU is a small, fixed-capacity LRU of decompressed value blocks,
+// keyed by block number. It exists to absorb short-range reuse across value
+// blocks that fall outside the single-entry fast path maintained directly by
+// valueBlockFetcher -- e.g. MVCC scans that bounce between a handful of
+// recently-visited value blocks. Entries are evicted and released in
+// least-recently-used order.
+type valueBlockLRU struct {
+	maxLen  int
+	entries []valueBlockLRUEntry
+}
+
+func (lru *valueBlockLRU) init(maxLen int) {
+	lru.maxLen = maxLen
+}
+
+// take removes and returns the entry for blockNum, if present.
+func (lru *valueBlockLRU) take(blockNum uint32) (valueBlockLRUEntry, bool) {
+	for i := range lru.entries {
+		if lru.entries[i].blockNum == blockNum {
+			e := lru.entries[i]
+			lru.entries = append(lru.entries[:i], lru.entries[i+1:]...)
+			return e, true
+		}
+	}
+	return valueBlockLRUEntry{}, false
+}
+
+// insert adds e to the most-recently-used position, evicting and releasing
+// the least-recently-used entry if the LRU is already at capacity.
+func (lru *valueBlockLRU) insert(e valueBlockLRUEntry) {
+	if lru.maxLen <= 0 {
+		e.cache.Release()
+		return
+	}
+	if len(lru.entries) == lru.maxLen {
+		evicted := lru.entries[len(lru.entries)-1]
+		evicted.cache.Release()
+		lru.entries = lru.entries[:len(lru.entries)-1]
+	}
+	lru.entries = append(lru.entries, valueBlockLRUEntry{})
+	copy(lru.entries[1:], lru.entries[:len(lru.entries)-1])
+	lru.entries[0] = e
+}
+
+// release releases all cache handles held by the LRU and empties it.
+func (lru *valueBlockLRU) release() {
+	for i := range lru.entries {
+		lru.entries[i].cache.Release()
+	}
+	lru.entries = nil
+}
+
+// valueBlockPrefetcher speculatively warms upcoming value blocks while a
+// valueBlockFetcher observes monotonically increasing value-block numbers
+// across successive Fetch calls, as happens during a sequential scan over
+// separated values. It overlaps the I/O for those blocks with the caller's
+// CPU work. It is a no-op unless the fetcher's blockProviderWhenOpen also
+// implements blockProviderWhenOpenAsync; in particular it is always disabled
+// when the fetcher is used via blockProviderWhenClosed.
+type valueBlockPrefetcher struct {
+	ctx      context.Context
+	async    blockProviderWhenOpenAsync
+	n        int
+	haveLast bool
+	lastSeen uint32
+	inFlight map[uint32]<-chan asyncValueBlockResult
+}
+
+// init configures the prefetcher to look ahead n value blocks. It is a no-op
+// if bpOpen does not implement blockProviderWhenOpenAsync or n <= 0.
+//
+// The prefetcher is a background activity that outlives any single Fetch
+// call, so it deliberately does not reuse a caller's per-Fetch context (doing
+// so would tie every subsequent background read's deadline/cancellation/
+// tracing span to whichever unrelated call happened to trigger the first
+// Fetch). Instead it derives its own detached context, matching the pattern
+// blockProviderWhenClosed.readBlockForVBR already uses for reads that aren't
+// scoped to one caller.
+func (p *valueBlockPrefetcher) init(bpOpen blockProviderWhenOpen, n int) {
+	async, ok := bpOpen.(blockProviderWhenOpenAsync)
+	if !ok || n <= 0 {
+		return
+	}
+	p.ctx = objiotracing.WithBlockType(context.Background(), objiotracing.ValueBlock)
+	p.async = async
+	p.n = n
+	p.inFlight = make(map[uint32]<-chan asyncValueBlockResult)
+}
+
+func (p *valueBlockPrefetcher) enabled() bool {
+	return p.async != nil
+}
+
+// observe records that blockNum was just requested and kicks off
+// asynchronous reads for up to the next p.n value blocks after it, including
+// on the first call. If blockNum does not directly follow the previously
+// observed block number, the prefetches still in flight are cancelled first.
+func (p *valueBlockPrefetcher) observe(f *valueBlockFetcher, blockNum uint32) {
+	if !p.enabled() {
+		return
+	}
+	if p.haveLast && blockNum != p.lastSeen+1 {
+		// The sequential run was broken; the blocks we already kicked off are
+		// unlikely to be useful, so stop waiting on them.
+		p.cancel()
+	}
+	p.lastSeen, p.haveLast = blockNum, true
+	for i := uint32(1); i <= uint32(p.n); i++ {
+		next := blockNum + i
+		if _, ok := p.inFlight[next]; ok {
+			continue
+		}
+		vbh, err := f.getBlockHandle(next)
+		if err != nil {
+			// next is likely beyond the end of the value blocks; don't bother
+			// looking further ahead.
+			break
+		}
+		p.inFlight[next] = p.async.readBlockForVBRAsync(p.ctx, vbh, f.stats)
+	}
+}
+
+// take returns the prefetched block for blockNum, if a prefetch for it was
+// kicked off by observe, blocking until it completes if necessary.
+func (p *valueBlockPrefetcher) take(blockNum uint32) (block.BufferHandle, bool, error) {
+	if !p.enabled() {
+		return block.BufferHandle{}, false, nil
+	}
+	ch, ok := p.inFlight[blockNum]
+	if !ok {
+		return block.BufferHandle{}, false, nil
+	}
+	delete(p.inFlight, blockNum)
+	res := <-ch
+	return res.block, true, res.err
+}
+
+// cancel discards all in-flight prefetches. Reads that are still in flight
+// are drained and released in the background, so that cancel does not block
+// on pending I/O.
+func (p *valueBlockPrefetcher) cancel() {
+	for blockNum, ch := range p.inFlight {
+		delete(p.inFlight, blockNum)
+		go func(ch <-chan asyncValueBlockResult) {
+			if res := <-ch; res.err == nil {
+				res.block.Release()
+			}
+		}(ch)
+	}
+}
+
+func (p *valueBlockPrefetcher) close() {
+	p.cancel()
+	p.async = nil
+}
+
+// valueFetchKey identifies a single value handle for the purposes of
+// coalescing concurrent Fetch calls in valueFetchCoalescer.
+type valueFetchKey struct {
+	blockNum      uint32
+	offsetInBlock uint32
+	valueLen      uint32
+}
+
+// valueFetchCall is the shared state for one in-flight (or just-completed)
+// coalesced Fetch.
+type valueFetchCall struct {
+	done chan struct{}
+	val  []byte
+	err  error
+}
+
+// valueFetchCoalescer coalesces concurrent Fetch calls for the same
+// valueFetchKey, so that only one goroutine performs the underlying block
+// read while the others wait on it and then share the result. This is
+// opt-in (see the valueBlockFetcher.coalesce field) since it changes the
+// concurrency contract documented on base.ValueFetcher: ordinarily a given
+// ValueFetcher's Fetch is not expected to be called concurrently.
+type valueFetchCoalescer struct {
+	mu      sync.Mutex
+	pending map[valueFetchKey]*valueFetchCall
+}
+
+// do runs fn for key, unless a call for key is already in flight, in which
+// case it waits for that call and returns its result instead.
+func (c *valueFetchCoalescer) do(key valueFetchKey, fn func() ([]byte, error)) ([]byte, error) {
+	c.mu.Lock()
+	if c.pending == nil {
+		c.pending = make(map[valueFetchKey]*valueFetchCall)
+	}
+	if call, ok := c.pending[key]; ok {
+		c.mu.Unlock()
+		<-call.done
+		return call.val, call.err
+	}
+	call := &valueFetchCall{done: make(chan struct{})}
+	c.pending[key] = call
+	c.mu.Unlock()
+
+	call.val, call.err = fn()
+
+	c.mu.Lock()
+	delete(c.pending, key)
+	c.mu.Unlock()
+	close(call.done)
+	return call.val, call.err
+}
+
+// defaultDecompressedValueCacheSize is the number of individually-compressed
+// values, including the one most recently decompressed, that a
+// valueBlockFetcher retains in decompressed form. It exists to avoid repeatedly paying decompression
+// cost for a value that is read more than once in short succession.
+const defaultDecompressedValueCacheSize = 4
+
+// decompressedValueEntry is one entry in decompressedValueCache, identified
+// by its location within the (still sstable-block-compressed) value block.
+type decompressedValueEntry struct {
+	blockNum      uint32
+	offsetInBlock uint32
+	value         []byte
+}
+
+// decompressedValueCache is a small, fixed-capacity LRU of individually
+// decompressed values, used when valblk.IndexHandle.ValuesIndividuallyCompressed
+// is set. Unlike valueBlockLRU, entries here are plain heap-allocated byte
+// slices rather than block cache handles, since each entry is just one
+// value, not a whole block.
+type decompressedValueCache struct {
+	maxLen  int
+	entries []decompressedValueEntry
+}
+
+func (c *decompressedValueCache) init(maxLen int) {
+	c.maxLen = maxLen
+}
+
+func (c *decompressedValueCache) get(blockNum, offsetInBlock uint32) ([]byte, bool) {
+	for i := range c.entries {
+		if c.entries[i].blockNum == blockNum && c.entries[i].offsetInBlock == offsetInBlock {
+			if i != 0 {
+				e := c.entries[i]
+				copy(c.entries[1:i+1], c.entries[:i])
+				c.entries[0] = e
+			}
+			return c.entries[0].value, true
+		}
+	}
+	return nil, false
+}
+
+func (c *decompressedValueCache) insert(e decompressedValueEntry) {
+	if c.maxLen <= 0 {
+		return
+	}
+	if len(c.entries) == c.maxLen {
+		c.entries = c.entries[:len(c.entries)-1]
+	}
+	c.entries = append(c.entries, decompressedValueEntry{})
+	copy(c.entries[1:], c.entries[:len(c.entries)-1])
+	c.entries[0] = e
+}
+
 // valueBlockFetcher implements base.ValueFetcher and is used through LazyValue
 // to fetch a value from a value block. The lifetime of this object is not tied
 // to the lifetime of the iterator - a LazyValue can be accessed later.
@@ -187,8 +624,27 @@ type valueBlockFetcher struct {
 	valueBlock    []byte
 	valueBlockPtr unsafe.Pointer
 	valueCache    block.BufferHandle
-	closed        bool
-	bufToMangle   []byte
+	// lru holds decompressed value blocks displaced from the fast path above,
+	// absorbing locality that the single-entry fast path otherwise misses.
+	lru valueBlockLRU
+	// decompressedValues caches individually-decompressed values when
+	// vbih.ValuesIndividuallyCompressed is set; see decompressValue.
+	decompressedValues decompressedValueCache
+	// prefetcher speculatively prefetches value blocks ahead of a sequential
+	// run of Fetch calls; see newValueBlockFetcher and valueBlockPrefetcher.
+	prefetcher valueBlockPrefetcher
+	// coalesce enables opt-in coalescing of concurrent Fetch calls that land
+	// on the same value handle, and makes Fetch safe to call concurrently.
+	// It is sourced from ReaderOptions (see WithValueFetchCoalescing); nil
+	// (the default) preserves the existing single-goroutine-at-a-time
+	// contract on base.ValueFetcher.
+	coalesce *valueFetchCoalescer
+	// stateMu, when coalesce is non-nil, guards the mutable fetch state above
+	// (vbiBlock, valueBlock/valueBlockNum/valueCache, lru) so that concurrent
+	// Fetch calls for distinct value handles don't race on it.
+	stateMu     sync.Mutex
+	closed      bool
+	bufToMangle []byte
 
 	// lazyFetcher is the LazyFetcher value embedded in any LazyValue that we
 	// return. It is used to avoid having a separate allocation for that.
@@ -202,13 +658,57 @@ func newValueBlockFetcher(
 	rp ReaderProvider,
 	vbih valblk.IndexHandle,
 	stats *base.InternalIteratorStats,
+	lruSize int,
+	prefetchCount int,
 ) *valueBlockFetcher {
-	return &valueBlockFetcher{
+	f := &valueBlockFetcher{
 		bpOpen: bpOpen,
 		rp:     rp,
 		vbih:   vbih,
 		stats:  stats,
 	}
+	f.lru.init(lruSize)
+	f.decompressedValues.init(defaultDecompressedValueCacheSize)
+	f.prefetcher.init(bpOpen, prefetchCount)
+	return f
+}
+
+// fetchOne retrieves a single value, honoring f.coalesce when set.
+//
+// When coalescing is disabled (the default), Fetch is only ever called by
+// one goroutine at a time for a given valueBlockFetcher, and the returned
+// value aliases fetcher-owned state (f.valueBlock, or an entry of
+// f.decompressedValues) that remains valid only until the next Fetch call,
+// per the existing base.ValueFetcher contract.
+//
+// When coalescing is enabled, Fetch may be called concurrently by multiple
+// goroutines. The value is therefore copied out of fetcher-owned state while
+// still holding stateMu: releasing stateMu first and copying after would let
+// a concurrent fetchOne for a different value handle run
+// valueBlockLRU.insert and evict-and-Release() the very block this value
+// aliases before the copy happens. That copy is shared by every caller
+// coalesced onto the same call, so each caller is returned its own fresh,
+// caller-owned copy of it.
+func (f *valueBlockFetcher) fetchOne(handle []byte, valLen int32) (val []byte, callerOwned bool, err error) {
+	if f.coalesce == nil {
+		val, err = f.getValueInternal(handle, valLen)
+		return val, false, err
+	}
+	vh := valblk.DecodeRemainingHandle(handle)
+	key := valueFetchKey{blockNum: vh.BlockNum, offsetInBlock: vh.OffsetInBlock, valueLen: uint32(valLen)}
+	val, err = f.coalesce.do(key, func() ([]byte, error) {
+		f.stateMu.Lock()
+		defer f.stateMu.Unlock()
+		v, err := f.getValueInternal(handle, valLen)
+		if err != nil {
+			return nil, err
+		}
+		return append([]byte(nil), v...), nil
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return append([]byte(nil), val...), true, nil
 }
 
 // Fetch implements base.ValueFetcher.
@@ -216,11 +716,13 @@ func (f *valueBlockFetcher) Fetch(
 	ctx context.Context, handle []byte, valLen int32, buf []byte,
 ) (val []byte, callerOwned bool, err error) {
 	if !f.closed {
-		val, err := f.getValueInternal(handle, valLen)
-		if invariants.Enabled {
+		val, callerOwned, err := f.fetchOne(handle, valLen)
+		// Only a fetcher-owned value is mangled: a caller-owned one must not be
+		// swapped for f.bufToMangle, which the next Fetch may clear.
+		if invariants.Enabled && !callerOwned {
 			val = f.doValueMangling(val)
 		}
-		return val, false, err
+		return val, callerOwned, err
 	}
 
 	bp := blockProviderWhenClosed{rp: f.rp}
@@ -232,6 +734,9 @@ func (f *valueBlockFetcher) Fetch(
 	defer f.close()
 	f.bpOpen = bp
 	var v []byte
+	// The prefetcher is never used here: blockProviderWhenClosed does not
+	// implement blockProviderWhenOpenAsync, and in any case bp does not
+	// outlive this call.
 	v, err = f.getValueInternal(handle, valLen)
 	if err != nil {
 		return nil, false, err
@@ -240,6 +745,77 @@ func (f *valueBlockFetcher) Fetch(
 	return buf, true, nil
 }
 
+// fetchOrder describes the position of a single FetchBatch handle relative
+// to the value block it points into, so that handles can be regrouped by
+// block for fetching and then scattered back to the caller's original order
+// via idx.
+type fetchOrder struct {
+	blockNum      uint32
+	offsetInBlock uint32
+	idx           int
+}
+
+// sortFetchOrder sorts order by (blockNum, offsetInBlock) so that entries
+// sharing a block end up adjacent, regardless of the order idx appears in.
+func sortFetchOrder(order []fetchOrder) {
+	sort.Slice(order, func(a, b int) bool {
+		if order[a].blockNum != order[b].blockNum {
+			return order[a].blockNum < order[b].blockNum
+		}
+		return order[a].offsetInBlock < order[b].offsetInBlock
+	})
+}
+
+// FetchBatch implements base.ValueFetcher's batched retrieval, amortizing
+// value-block decode/retrieve cost across many handles that may land in the
+// same value block (e.g. a batch Get touching many keys with separated
+// values). It sorts the handles by block, so that in the common case of no
+// concurrent access to this fetcher each distinct value block is read at
+// most once regardless of how many of the batch's handles point into it, and
+// returns values in the caller's original order. This is only a best effort
+// when WithValueFetchCoalescing is in effect: fetchOne always re-reads on a
+// miss, so a concurrent Fetch for an unrelated handle can still evict a
+// block between two of this batch's entries that share it, costing an extra
+// read rather than correctness.
+func (f *valueBlockFetcher) FetchBatch(
+	ctx context.Context, handles [][]byte, valLens []int32, bufs [][]byte,
+) ([][]byte, []bool, error) {
+	if len(handles) != len(valLens) {
+		return nil, nil, base.AssertionFailedf(
+			"mismatched handles (%d) and valLens (%d) in FetchBatch", len(handles), len(valLens))
+	}
+	order := make([]fetchOrder, len(handles))
+	for i, h := range handles {
+		vh := valblk.DecodeRemainingHandle(h)
+		order[i] = fetchOrder{blockNum: vh.BlockNum, offsetInBlock: vh.OffsetInBlock, idx: i}
+	}
+	sortFetchOrder(order)
+
+	vals := make([][]byte, len(handles))
+	callerOwned := make([]bool, len(handles))
+	for _, o := range order {
+		// Route through fetchOne, not getValueInternal directly, so that a
+		// FetchBatch call shares the same stateMu/coalesce discipline as Fetch:
+		// without it, a concurrent Fetch on this same fetcher (once
+		// WithValueFetchCoalescing is in effect) could race with this loop's
+		// LRU inserts and evict-and-Release() a block out from under it.
+		v, _, err := f.fetchOne(handles[o.idx], valLens[o.idx])
+		if err != nil {
+			return nil, nil, err
+		}
+		var buf []byte
+		if o.idx < len(bufs) {
+			buf = bufs[o.idx]
+		}
+		// v aliases f.valueBlock (or a prior fast-path block), which may be
+		// evicted or reused by a later iteration of this loop, so it must be
+		// copied out before we move on to the next handle.
+		vals[o.idx] = append(buf[:0], v...)
+		callerOwned[o.idx] = true
+	}
+	return vals, callerOwned, nil
+}
+
 func (f *valueBlockFetcher) close() {
 	f.vbiBlock = nil
 	f.vbiCache.Release()
@@ -253,6 +829,8 @@ func (f *valueBlockFetcher) close() {
 	f.valueCache.Release()
 	// See comment above.
 	f.valueCache = block.BufferHandle{}
+	f.lru.release()
+	f.prefetcher.close()
 	f.closed = true
 	// rp, vbih, stats remain valid, so that LazyFetcher.ValueFetcher can be
 	// implemented.
@@ -285,24 +863,129 @@ func (f *valueBlockFetcher) getValueInternal(handle []byte, valLen int32) (val [
 		f.vbiBlock = ch.BlockData()
 	}
 	if f.valueBlock == nil || f.valueBlockNum != vh.BlockNum {
-		vbh, err := f.getBlockHandle(vh.BlockNum)
-		if err != nil {
-			return nil, err
+		if f.valueBlock != nil {
+			// Displace the current fast-path block into the LRU, rather than
+			// releasing it outright, in case it is revisited soon.
+			f.lru.insert(valueBlockLRUEntry{
+				blockNum: f.valueBlockNum,
+				block:    f.valueBlock,
+				cache:    f.valueCache,
+			})
 		}
-		vbCacheHandle, err := f.bpOpen.readBlockForVBR(vbh, f.stats)
-		if err != nil {
-			return nil, err
+		if e, ok := f.lru.take(vh.BlockNum); ok {
+			f.valueBlockNum = e.blockNum
+			f.valueCache = e.cache
+			f.valueBlock = e.block
+			f.valueBlockPtr = unsafe.Pointer(&f.valueBlock[0])
+		} else if vbCacheHandle, ok, err := f.prefetcher.take(vh.BlockNum); ok || err != nil {
+			if err != nil {
+				return nil, err
+			}
+			f.valueBlockNum = vh.BlockNum
+			f.valueCache = vbCacheHandle
+			f.valueBlock = vbCacheHandle.BlockData()
+			f.valueBlockPtr = unsafe.Pointer(&f.valueBlock[0])
+		} else {
+			vbh, err := f.getBlockHandle(vh.BlockNum)
+			if err != nil {
+				return nil, err
+			}
+			vbCacheHandle, err := f.bpOpen.readBlockForVBR(vbh, f.stats)
+			if err != nil {
+				return nil, err
+			}
+			f.valueBlockNum = vh.BlockNum
+			f.valueCache = vbCacheHandle
+			f.valueBlock = vbCacheHandle.BlockData()
+			f.valueBlockPtr = unsafe.Pointer(&f.valueBlock[0])
 		}
-		f.valueBlockNum = vh.BlockNum
-		f.valueCache.Release()
-		f.valueCache = vbCacheHandle
-		f.valueBlock = vbCacheHandle.BlockData()
-		f.valueBlockPtr = unsafe.Pointer(&f.valueBlock[0])
+		f.prefetcher.observe(f, vh.BlockNum)
 	}
 	if f.stats != nil {
 		f.stats.SeparatedPointValue.ValueBytesFetched += uint64(valLen)
 	}
-	return f.valueBlock[vh.OffsetInBlock : vh.OffsetInBlock+vh.ValueLen], nil
+	raw := f.valueBlock[vh.OffsetInBlock : vh.OffsetInBlock+vh.ValueLen]
+	if !f.vbih.ValuesIndividuallyCompressed {
+		return raw, nil
+	}
+	return f.decompressValue(vh.BlockNum, vh.OffsetInBlock, raw)
+}
+
+// decompressValue decodes a single individually-compressed value, as used
+// when vbih.ValuesIndividuallyCompressed is set: raw is a 1-byte
+// valueCompressionTag, followed by the (possibly compressed) payload,
+// followed by a trailing valuesCompressionChecksumLen-byte CRC-32C checksum
+// of everything preceding it. This lets a reader decompress a single large
+// separated value without paying to decompress the whole sstable block it
+// resides in, at the cost of forgoing cross-value compression.
+//
+// Results are cached in f.decompressedValues, since the decompressed value
+// returned here may be requested again shortly (e.g. by a caller that reads
+// the same key's value more than once).
+func (f *valueBlockFetcher) decompressValue(
+	blockNum, offsetInBlock uint32, raw []byte,
+) ([]byte, error) {
+	if v, ok := f.decompressedValues.get(blockNum, offsetInBlock); ok {
+		return v, nil
+	}
+	if len(raw) < 1+valuesCompressionChecksumLen {
+		return nil, base.CorruptionErrorf("invalid individually-compressed value: length %d", len(raw))
+	}
+	payload := raw[:len(raw)-valuesCompressionChecksumLen]
+	wantChecksum := binary.LittleEndian.Uint32(raw[len(raw)-valuesCompressionChecksumLen:])
+	if gotChecksum := crc32.Checksum(payload, valuesChecksumTable); gotChecksum != wantChecksum {
+		return nil, base.CorruptionErrorf(
+			"checksum mismatch for individually-compressed value: got %d want %d", gotChecksum, wantChecksum)
+	}
+	tag := valueCompressionTag(payload[0])
+	compressed := payload[1:]
+	var v []byte
+	switch tag {
+	case valueCompressionNone:
+		// compressed aliases f.valueBlock, a block-cache-backed buffer that may
+		// be released back to the cache (and reused) once evicted from f.lru,
+		// so it must be copied before being cached in f.decompressedValues or
+		// returned to the caller.
+		v = append([]byte(nil), compressed...)
+	case valueCompressionSnappy:
+		decodedLen, err := snappy.DecodedLen(compressed)
+		if err != nil {
+			return nil, errors.Wrap(err, "decoding snappy-compressed value")
+		}
+		v, err = snappy.Decode(make([]byte, decodedLen), compressed)
+		if err != nil {
+			return nil, errors.Wrap(err, "decoding snappy-compressed value")
+		}
+	case valueCompressionZstd:
+		dec, err := getZstdDecoder()
+		if err != nil {
+			return nil, err
+		}
+		v, err = dec.DecodeAll(compressed, nil)
+		if err != nil {
+			return nil, errors.Wrap(err, "decoding zstd-compressed value")
+		}
+	default:
+		return nil, base.CorruptionErrorf("unknown value compression tag %d", tag)
+	}
+	f.decompressedValues.insert(decompressedValueEntry{blockNum: blockNum, offsetInBlock: offsetInBlock, value: v})
+	return v, nil
+}
+
+// zstdDecoderOnce lazily constructs zstdDecoder, a single, reusable
+// zstd.Decoder shared by all values decompressed via decompressValue. A
+// zstd.Decoder is safe for concurrent use by multiple goroutines.
+var (
+	zstdDecoderOnce sync.Once
+	zstdDecoder     *zstd.Decoder
+	zstdDecoderErr  error
+)
+
+func getZstdDecoder() (*zstd.Decoder, error) {
+	zstdDecoderOnce.Do(func() {
+		zstdDecoder, zstdDecoderErr = zstd.NewReader(nil)
+	})
+	return zstdDecoder, zstdDecoderErr
 }
 
 func (f *valueBlockFetcher) getBlockHandle(blockNum uint32) (block.Handle, error) {
@@ -0,0 +1,480 @@
+// Copyright 2022 The LevelDB-Go and Pebble Authors. All rights reserved. Use
+// of this source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+
+package sstable
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/pebble/internal/base"
+	"github.com/cockroachdb/pebble/sstable/block"
+	"github.com/cockroachdb/pebble/sstable/valblk"
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+)
+
+// TestValueFetchCoalescerSameKey verifies that concurrent do() calls for the
+// same key are coalesced into a single execution of fn, with every caller
+// observing its result. The first call is launched alone and held in flight
+// (blocked on release) until fn has actually started, so none of the
+// remaining calls can be the first to register the key. The first call is
+// only released once every remaining caller has been started and given time
+// to reach do() and find the key pending.
+func TestValueFetchCoalescerSameKey(t *testing.T) {
+	var c valueFetchCoalescer
+	var executions atomic.Int32
+	key := valueFetchKey{blockNum: 1, offsetInBlock: 2, valueLen: 3}
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	const n = 20
+	results := make([][]byte, n)
+	var wg sync.WaitGroup
+	wg.Add(n)
+
+	go func() {
+		defer wg.Done()
+		v, err := c.do(key, func() ([]byte, error) {
+			executions.Add(1)
+			close(started)
+			<-release
+			return []byte("value"), nil
+		})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		results[0] = v
+	}()
+	<-started
+
+	var ready sync.WaitGroup
+	ready.Add(n - 1)
+	for i := 1; i < n; i++ {
+		i := i
+		go func() {
+			defer wg.Done()
+			ready.Done()
+			v, err := c.do(key, func() ([]byte, error) {
+				executions.Add(1)
+				return []byte("value"), nil
+			})
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			results[i] = v
+		}()
+	}
+	ready.Wait()
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if got := executions.Load(); got != 1 {
+		t.Fatalf("fn executed %d times, want 1", got)
+	}
+	for i, v := range results {
+		if string(v) != "value" {
+			t.Fatalf("result %d = %q, want %q", i, v, "value")
+		}
+	}
+}
+
+// TestValueFetchCoalescerDistinctKeys verifies that do() for distinct keys
+// can make progress concurrently -- a slow call for one key must not block
+// a call for an unrelated key.
+func TestValueFetchCoalescerDistinctKeys(t *testing.T) {
+	var c valueFetchCoalescer
+	blocked := make(chan struct{})
+	unblock := make(chan struct{})
+
+	go func() {
+		_, _ = c.do(valueFetchKey{blockNum: 1}, func() ([]byte, error) {
+			close(blocked)
+			<-unblock
+			return []byte("slow"), nil
+		})
+	}()
+
+	<-blocked
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		v, err := c.do(valueFetchKey{blockNum: 2}, func() ([]byte, error) {
+			return []byte("fast"), nil
+		})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if string(v) != "fast" {
+			t.Errorf("result = %q, want %q", v, "fast")
+		}
+	}()
+	<-done
+	close(unblock)
+}
+
+// TestDecompressedValueCache exercises the bounded LRU used to cache
+// individually-decompressed values (see decompressedValueCache).
+func TestDecompressedValueCache(t *testing.T) {
+	var c decompressedValueCache
+	c.init(2)
+
+	for i := uint32(0); i < 2; i++ {
+		c.insert(decompressedValueEntry{blockNum: i, offsetInBlock: i, value: []byte(fmt.Sprintf("v%d", i))})
+	}
+	for i := uint32(0); i < 2; i++ {
+		v, ok := c.get(i, i)
+		if !ok || string(v) != fmt.Sprintf("v%d", i) {
+			t.Fatalf("get(%d) = %q, %v; want hit", i, v, ok)
+		}
+	}
+
+	// Inserting a third entry should evict the least-recently-used one. Entry
+	// 1 was just promoted to the front by the get() loop above, so entry 0 is
+	// now the least-recently-used and should be evicted.
+	c.insert(decompressedValueEntry{blockNum: 2, offsetInBlock: 2, value: []byte("v2")})
+	if _, ok := c.get(0, 0); ok {
+		t.Fatalf("get(0) hit after eviction, want miss")
+	}
+	if v, ok := c.get(1, 1); !ok || string(v) != "v1" {
+		t.Fatalf("get(1) = %q, %v; want hit with %q", v, ok, "v1")
+	}
+	if v, ok := c.get(2, 2); !ok || string(v) != "v2" {
+		t.Fatalf("get(2) = %q, %v; want hit with %q", v, ok, "v2")
+	}
+}
+
+// TestValueBlockLRU exercises the take/insert eviction ordering of
+// valueBlockLRU, the bounded cache of decompressed value blocks evicted from
+// valueBlockFetcher's single-entry fast path.
+func TestValueBlockLRU(t *testing.T) {
+	var lru valueBlockLRU
+	lru.init(2)
+	lru.insert(valueBlockLRUEntry{blockNum: 0, block: []byte("b0")})
+	lru.insert(valueBlockLRUEntry{blockNum: 1, block: []byte("b1")})
+	lru.insert(valueBlockLRUEntry{blockNum: 2, block: []byte("b2")})
+
+	if _, ok := lru.take(0); ok {
+		t.Fatalf("take(0) hit after eviction, want miss")
+	}
+	e, ok := lru.take(1)
+	if !ok || !bytes.Equal(e.block, []byte("b1")) {
+		t.Fatalf("take(1) = %+v, %v; want hit with b1", e, ok)
+	}
+	if _, ok := lru.take(1); ok {
+		t.Fatalf("take(1) hit after being taken, want miss")
+	}
+	if e, ok := lru.take(2); !ok || !bytes.Equal(e.block, []byte("b2")) {
+		t.Fatalf("take(2) = %+v, %v; want hit with b2", e, ok)
+	}
+}
+
+// TestValueBlockLRUDisabled verifies that a zero-maxLen valueBlockLRU (as
+// configured by WithValueBlockLRUSize(0)) never retains entries.
+func TestValueBlockLRUDisabled(t *testing.T) {
+	var lru valueBlockLRU
+	lru.init(0)
+	lru.insert(valueBlockLRUEntry{blockNum: 0, block: []byte("b0")})
+
+	if _, ok := lru.take(0); ok {
+		t.Fatalf("take(0) hit on a disabled LRU, want miss")
+	}
+}
+
+// TestValueBlockLRURelease verifies that release empties the LRU.
+func TestValueBlockLRURelease(t *testing.T) {
+	var lru valueBlockLRU
+	lru.init(2)
+	lru.insert(valueBlockLRUEntry{blockNum: 0, block: []byte("b0")})
+	lru.insert(valueBlockLRUEntry{blockNum: 1, block: []byte("b1")})
+
+	lru.release()
+	if len(lru.entries) != 0 {
+		t.Fatalf("release() left %d entries, want 0", len(lru.entries))
+	}
+	if _, ok := lru.take(0); ok {
+		t.Fatalf("take(0) hit after release(), want miss")
+	}
+}
+
+// TestSortFetchOrder verifies that sortFetchOrder groups entries that share
+// a block together (ordered by offset within the block), regardless of how
+// those entries were originally interleaved or duplicated in the batch.
+func TestSortFetchOrder(t *testing.T) {
+	// idx values are deliberately shuffled, and block 2 appears twice
+	// (duplicate handles into the same block from different batch slots), to
+	// mirror a batch Get touching many keys with separated values.
+	order := []fetchOrder{
+		{blockNum: 2, offsetInBlock: 5, idx: 0},
+		{blockNum: 0, offsetInBlock: 10, idx: 1},
+		{blockNum: 2, offsetInBlock: 1, idx: 2},
+		{blockNum: 1, offsetInBlock: 0, idx: 3},
+		{blockNum: 0, offsetInBlock: 3, idx: 4},
+	}
+	sortFetchOrder(order)
+
+	want := []fetchOrder{
+		{blockNum: 0, offsetInBlock: 3, idx: 4},
+		{blockNum: 0, offsetInBlock: 10, idx: 1},
+		{blockNum: 1, offsetInBlock: 0, idx: 3},
+		{blockNum: 2, offsetInBlock: 1, idx: 2},
+		{blockNum: 2, offsetInBlock: 5, idx: 0},
+	}
+	if !reflect.DeepEqual(order, want) {
+		t.Fatalf("sortFetchOrder() = %+v, want %+v", order, want)
+	}
+}
+
+// TestFetchBatchOrderRestoration verifies that the idx carried through
+// sortFetchOrder is enough for a scatter loop like FetchBatch's to land
+// results back at their caller-original index, even with blocks visited out
+// of batch order and the same block appearing at multiple indices. It runs
+// its own copy of that loop; FetchBatch itself is not called.
+func TestFetchBatchOrderRestoration(t *testing.T) {
+	order := []fetchOrder{
+		{blockNum: 2, offsetInBlock: 5, idx: 0},
+		{blockNum: 0, offsetInBlock: 10, idx: 1},
+		{blockNum: 2, offsetInBlock: 1, idx: 2},
+		{blockNum: 1, offsetInBlock: 0, idx: 3},
+		{blockNum: 0, offsetInBlock: 3, idx: 4},
+	}
+	sortFetchOrder(order)
+
+	// Simulate FetchBatch's scatter loop: each order entry's "fetched value"
+	// is just a string identifying which (block, offset) produced it, so we
+	// can check it lands at the right original idx afterward.
+	vals := make([]string, 5)
+	for _, o := range order {
+		vals[o.idx] = fmt.Sprintf("block%d:%d", o.blockNum, o.offsetInBlock)
+	}
+	want := []string{"block2:5", "block0:10", "block2:1", "block1:0", "block0:3"}
+	for i := range want {
+		if vals[i] != want[i] {
+			t.Fatalf("vals[%d] = %q, want %q", i, vals[i], want[i])
+		}
+	}
+}
+
+// encodeCompressedValue builds the on-disk representation decompressValue
+// expects: a 1-byte valueCompressionTag, the (possibly compressed) payload,
+// and a trailing CRC-32C checksum of everything preceding it.
+func encodeCompressedValue(t *testing.T, tag valueCompressionTag, value []byte) []byte {
+	t.Helper()
+	var compressed []byte
+	switch tag {
+	case valueCompressionNone:
+		compressed = value
+	case valueCompressionSnappy:
+		compressed = snappy.Encode(nil, value)
+	case valueCompressionZstd:
+		enc, err := zstd.NewWriter(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		compressed = enc.EncodeAll(value, nil)
+	}
+	payload := append([]byte{byte(tag)}, compressed...)
+	checksum := crc32.Checksum(payload, valuesChecksumTable)
+	checksumBuf := make([]byte, valuesCompressionChecksumLen)
+	binary.LittleEndian.PutUint32(checksumBuf, checksum)
+	return append(payload, checksumBuf...)
+}
+
+// TestDecompressValueRoundTrip exercises decompressValue for every
+// valueCompressionTag, and verifies that the returned (and cached) value
+// does not alias its raw input -- the raw slice it decodes from aliases
+// f.valueBlock, a block-cache buffer that may be mutated or released back to
+// the cache once evicted from f.lru.
+func TestDecompressValueRoundTrip(t *testing.T) {
+	for _, tag := range []valueCompressionTag{valueCompressionNone, valueCompressionSnappy, valueCompressionZstd} {
+		tag := tag
+		t.Run("", func(t *testing.T) {
+			var f valueBlockFetcher
+			f.decompressedValues.init(2)
+			want := []byte("hello world, this is a separated value")
+			raw := encodeCompressedValue(t, tag, want)
+
+			got, err := f.decompressValue(0, 0, raw)
+			if err != nil {
+				t.Fatalf("decompressValue: %v", err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Fatalf("decompressValue = %q, want %q", got, want)
+			}
+
+			// Clobbering raw after the call must not corrupt the cached value.
+			for i := range raw {
+				raw[i] = 0xff
+			}
+			got2, ok := f.decompressedValues.get(0, 0)
+			if !ok {
+				t.Fatalf("expected cache hit after decompressValue")
+			}
+			if !bytes.Equal(got2, want) {
+				t.Fatalf("cached value = %q after clobbering raw, want %q (use-after-free)", got2, want)
+			}
+		})
+	}
+}
+
+// TestDecompressValueChecksumMismatch verifies that decompressValue rejects
+// an individually-compressed value whose trailing checksum doesn't match.
+func TestDecompressValueChecksumMismatch(t *testing.T) {
+	var f valueBlockFetcher
+	f.decompressedValues.init(2)
+	raw := encodeCompressedValue(t, valueCompressionNone, []byte("value"))
+	raw[0] ^= 0xff // corrupt the tag byte, invalidating the checksum
+
+	if _, err := f.decompressValue(0, 0, raw); err == nil {
+		t.Fatalf("decompressValue succeeded on corrupted input, want checksum error")
+	}
+}
+
+// TestDecompressValueTooShort verifies that decompressValue rejects input
+// too short to contain a tag byte and checksum.
+func TestDecompressValueTooShort(t *testing.T) {
+	var f valueBlockFetcher
+	f.decompressedValues.init(2)
+	if _, err := f.decompressValue(0, 0, []byte{0}); err == nil {
+		t.Fatalf("decompressValue succeeded on truncated input, want error")
+	}
+}
+
+// fakeAsyncProvider implements blockProviderWhenOpen and
+// blockProviderWhenOpenAsync, recording the blocks it's asked to prefetch and
+// handing back an immediately-ready result for each.
+type fakeAsyncProvider struct {
+	requested []uint64
+}
+
+func (p *fakeAsyncProvider) readBlockForVBR(
+	h block.Handle, stats *base.InternalIteratorStats,
+) (block.BufferHandle, error) {
+	return block.BufferHandle{}, nil
+}
+
+func (p *fakeAsyncProvider) readBlockForVBRAsync(
+	ctx context.Context, h block.Handle, stats *base.InternalIteratorStats,
+) <-chan asyncValueBlockResult {
+	p.requested = append(p.requested, h.Offset)
+	ch := make(chan asyncValueBlockResult, 1)
+	ch <- asyncValueBlockResult{block: block.BufferHandle{}}
+	return ch
+}
+
+var _ blockProviderWhenOpen = (*fakeAsyncProvider)(nil)
+var _ blockProviderWhenOpenAsync = (*fakeAsyncProvider)(nil)
+
+// syncOnlyProvider implements blockProviderWhenOpen but not
+// blockProviderWhenOpenAsync, like blockProviderWhenClosed.
+type syncOnlyProvider struct{}
+
+func (syncOnlyProvider) readBlockForVBR(
+	h block.Handle, stats *base.InternalIteratorStats,
+) (block.BufferHandle, error) {
+	return block.BufferHandle{}, nil
+}
+
+// newPrefetchTestFetcher builds a valueBlockFetcher with a synthetic value
+// blocks index of numBlocks entries (1-byte block num, offset, and length
+// fields), each block's offset equal to 10*blockNum, so getBlockHandle is
+// exercised the same way it would be against a real index block.
+func newPrefetchTestFetcher(numBlocks int) *valueBlockFetcher {
+	var vbiBlock []byte
+	for i := 0; i < numBlocks; i++ {
+		vbiBlock = append(vbiBlock, byte(i), byte(i*10), 5)
+	}
+	return &valueBlockFetcher{
+		vbih: valblk.IndexHandle{
+			BlockNumByteLength:    1,
+			BlockOffsetByteLength: 1,
+			BlockLengthByteLength: 1,
+		},
+		vbiBlock: vbiBlock,
+	}
+}
+
+// TestValueBlockPrefetcherObserve verifies that observe kicks off prefetches
+// for up to n upcoming blocks, and that take drains exactly the blocks that
+// were actually prefetched.
+func TestValueBlockPrefetcherObserve(t *testing.T) {
+	p := &fakeAsyncProvider{}
+	f := newPrefetchTestFetcher(5)
+	f.prefetcher.init(p, 2)
+
+	f.prefetcher.observe(f, 0)
+	if want := []uint64{10, 20}; !uint64SliceEqual(p.requested, want) {
+		t.Fatalf("requested = %v, want %v", p.requested, want)
+	}
+
+	if _, ok, err := f.prefetcher.take(3); ok || err != nil {
+		t.Fatalf("take(3) = %v, %v; want a miss (never prefetched)", ok, err)
+	}
+	if _, ok, err := f.prefetcher.take(1); !ok || err != nil {
+		t.Fatalf("take(1) = %v, %v; want a hit", ok, err)
+	}
+	if _, ok, _ := f.prefetcher.take(1); ok {
+		t.Fatalf("take(1) hit after being drained, want miss")
+	}
+}
+
+// TestValueBlockPrefetcherCancelOnBrokenSequence verifies that observe
+// cancels outstanding prefetches when the run of block numbers is broken,
+// and that the cancelled blocks are no longer tracked.
+func TestValueBlockPrefetcherCancelOnBrokenSequence(t *testing.T) {
+	p := &fakeAsyncProvider{}
+	f := newPrefetchTestFetcher(7)
+	f.prefetcher.init(p, 2)
+
+	f.prefetcher.observe(f, 0) // kicks off prefetch for blocks 1, 2
+	f.prefetcher.observe(f, 4) // not 0+1, so the pending prefetches are cancelled; kicks off 5, 6
+
+	if len(f.prefetcher.inFlight) != 2 {
+		t.Fatalf("len(inFlight) = %d, want 2 (for blocks kicked off by the observe(4) call)",
+			len(f.prefetcher.inFlight))
+	}
+	if _, ok := f.prefetcher.inFlight[1]; ok {
+		t.Fatalf("prefetch for block 1 still tracked after the sequence was broken")
+	}
+	if _, ok, err := f.prefetcher.take(5); !ok || err != nil {
+		t.Fatalf("take(5) = %v, %v; want a hit", ok, err)
+	}
+}
+
+// TestValueBlockPrefetcherDisabled verifies that a prefetcher whose bpOpen
+// does not implement blockProviderWhenOpenAsync is a permanent no-op, as is
+// the case when the fetcher is used via blockProviderWhenClosed.
+func TestValueBlockPrefetcherDisabled(t *testing.T) {
+	f := newPrefetchTestFetcher(5)
+	f.prefetcher.init(syncOnlyProvider{}, 2)
+
+	f.prefetcher.observe(f, 0)
+	if _, ok, err := f.prefetcher.take(1); ok || err != nil {
+		t.Fatalf("take(1) = %v, %v; want a miss, prefetcher should be disabled", ok, err)
+	}
+}
+
+func uint64SliceEqual(a, b []uint64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}